@@ -0,0 +1,137 @@
+// Package cache provides an on-disk HTTP response cache keyed on request
+// URL, with conditional revalidation via ETag/Last-Modified. Recon
+// workflows re-run subjs across the same large URL lists repeatedly;
+// without this, every run re-downloads every chunk even when nothing
+// changed server-side.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// entry is what's persisted to disk per cached URL: the response body plus
+// the validators needed to conditionally revalidate it on a later run.
+type entry struct {
+	Body         []byte    `json:"body"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// Cache wraps an *http.Client with an on-disk store rooted at Dir.
+type Cache struct {
+	Dir     string
+	Client  *http.Client
+	TTL     time.Duration
+	Offline bool
+}
+
+// New returns a Cache rooted at dir, issuing live requests through client.
+// ttl of zero means a cached entry is always revalidated (rather than ever
+// being served on age alone); offline, when true, answers purely from disk
+// and never touches the network, failing if nothing is cached yet.
+func New(dir string, client *http.Client, ttl time.Duration, offline bool) *Cache {
+	return &Cache{Dir: dir, Client: client, TTL: ttl, Offline: offline}
+}
+
+// Get returns the body req's URL resolves to: from disk if it's within TTL
+// or the server confirms it's unchanged via a 304, from the network
+// otherwise. req is mutated with conditional-request headers when a cached
+// entry exists for its URL.
+func (c *Cache) Get(req *http.Request) ([]byte, error) {
+	key := c.keyFor(req.URL.String())
+	cached, ok := c.load(key)
+
+	if ok && c.Offline {
+		return cached.Body, nil
+	}
+	if !ok && c.Offline {
+		return nil, fmt.Errorf("offline: no cached response for %s", req.URL)
+	}
+	if ok && c.TTL > 0 && time.Since(cached.FetchedAt) < c.TTL {
+		return cached.Body, nil
+	}
+
+	if ok {
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		if ok {
+			// The network failed but we have a stale copy; prefer it over
+			// an empty result.
+			return cached.Body, nil
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && ok {
+		cached.FetchedAt = time.Now()
+		c.store(key, cached)
+		return cached.Body, nil
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only 2xx responses are trustworthy enough to replay on a later run;
+	// caching an error page would otherwise get served back as if it were
+	// real chunk content for the rest of the TTL window.
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		c.store(key, entry{
+			Body:         body,
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+			FetchedAt:    time.Now(),
+		})
+	}
+	return body, nil
+}
+
+// keyFor returns the on-disk path for url's cache entry, hashed so the
+// original URL never has to round-trip through filesystem-unsafe
+// characters.
+func (c *Cache) keyFor(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *Cache) load(path string) (entry, bool) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return entry{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return entry{}, false
+	}
+	return e, true
+}
+
+func (c *Cache) store(path string, e entry) {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(path, data, 0o644)
+}
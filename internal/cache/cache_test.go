@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newRequest(t *testing.T, url string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		t.Fatalf("could not build request: %s", err)
+	}
+	return req
+}
+
+func TestGetFetchesAndCachesOnMiss(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("body-one"))
+	}))
+	defer srv.Close()
+
+	c := New(t.TempDir(), srv.Client(), time.Hour, false)
+	body, err := c.Get(newRequest(t, srv.URL))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(body) != "body-one" {
+		t.Fatalf("got body %q", body)
+	}
+	if hits != 1 {
+		t.Fatalf("expected 1 request to the server, got %d", hits)
+	}
+}
+
+func TestGetServesFromDiskWithinTTL(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	c := New(t.TempDir(), srv.Client(), time.Hour, false)
+	url := srv.URL
+	if _, err := c.Get(newRequest(t, url)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.Get(newRequest(t, url)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hits != 1 {
+		t.Fatalf("expected the second Get to be served from disk within TTL, server saw %d requests", hits)
+	}
+}
+
+func TestGetRevalidatesWith304(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("fresh body"))
+	}))
+	defer srv.Close()
+
+	// TTL of zero forces every Get to revalidate rather than short-circuit.
+	c := New(t.TempDir(), srv.Client(), 0, false)
+	url := srv.URL
+	first, err := c.Get(newRequest(t, url))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	second, err := c.Get(newRequest(t, url))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if string(first) != "fresh body" || string(second) != "fresh body" {
+		t.Fatalf("expected the cached body to survive a 304, got %q then %q", first, second)
+	}
+	if hits != 2 {
+		t.Fatalf("expected 2 requests (one 200, one 304), got %d", hits)
+	}
+}
+
+func TestGetOfflineHit(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte("body"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	online := New(dir, srv.Client(), time.Hour, false)
+	url := srv.URL
+	if _, err := online.Get(newRequest(t, url)); err != nil {
+		t.Fatalf("unexpected error priming the cache: %s", err)
+	}
+
+	offline := New(dir, srv.Client(), time.Hour, true)
+	body, err := offline.Get(newRequest(t, url))
+	if err != nil {
+		t.Fatalf("unexpected error serving an offline hit: %s", err)
+	}
+	if string(body) != "body" {
+		t.Fatalf("got body %q", body)
+	}
+	if hits != 1 {
+		t.Fatalf("offline Get must never touch the network, server saw %d requests", hits)
+	}
+}
+
+func TestGetOfflineMiss(t *testing.T) {
+	c := New(t.TempDir(), http.DefaultClient, time.Hour, true)
+	if _, err := c.Get(newRequest(t, "http://example.invalid/never-fetched.js")); err == nil {
+		t.Fatal("expected an error for an offline miss, got nil")
+	}
+}
+
+func TestGetDoesNotCacheErrorResponses(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("oops"))
+	}))
+	defer srv.Close()
+
+	c := New(t.TempDir(), srv.Client(), time.Hour, false)
+	url := srv.URL
+	if _, err := c.Get(newRequest(t, url)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := c.Get(newRequest(t, url)); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if hits != 2 {
+		t.Fatalf("expected an error response to never be served from cache, server saw %d requests", hits)
+	}
+}
+
+func TestGetFallsBackToStaleOnNetworkError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("body"))
+	}))
+
+	c := New(t.TempDir(), srv.Client(), 0, false)
+	url := srv.URL
+	if _, err := c.Get(newRequest(t, url)); err != nil {
+		t.Fatalf("unexpected error priming the cache: %s", err)
+	}
+	srv.Close()
+
+	body, err := c.Get(newRequest(t, url))
+	if err != nil {
+		t.Fatalf("expected the stale cached body instead of an error, got: %s", err)
+	}
+	if string(body) != "body" {
+		t.Fatalf("got body %q", body)
+	}
+}
@@ -0,0 +1,194 @@
+// Package jsparse extracts bundler chunk references from JavaScript source
+// by walking a real AST instead of pattern-matching one bundler's output.
+// Regexes like the ones ProcessWebpackFile used only ever cover the exact
+// shape of code one bundler version happened to emit; a parser generalizes
+// across webpack, Vite, Parcel, Rollup and esbuild-splitting output because
+// it recognizes the underlying expression shapes (object literals, string
+// concatenation, dynamic import()) rather than one framework's syntax.
+package jsparse
+
+import (
+	"strings"
+
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/js"
+)
+
+// Kind categorizes how a ChunkPath was discovered, mirroring the three
+// shapes bundlers use to reference split-out chunks.
+type Kind string
+
+const (
+	// KindManifest is a string literal inside an object/array literal that
+	// maps chunk ids to file paths (webpack's a.u map, Vite's manifest.json
+	// mirrored inline, Parcel's require.i, etc).
+	KindManifest Kind = "manifest"
+	// KindConcat is a string concatenation of entirely literal operands,
+	// e.g. "static/chunks/" + "main" + ".js". It does NOT cover the
+	// publicPath + "static/..." + hash + ".js" shape most bundlers actually
+	// emit, since publicPath and hash are identifiers, not literals - see
+	// concatenatedPath. That dynamic-hash case is only handled by the legacy
+	// regex patterns (processWebpackFileRegex's complexPattern, run under
+	// Options.LegacyRegex or when this AST pass finds nothing) or a
+	// matching Template extractor's "dictProduct"/"auFunction" kind.
+	KindConcat Kind = "concat"
+	// KindDynamicImport is the argument of an import("...") call expression.
+	KindDynamicImport Kind = "dynamic-import"
+)
+
+// ChunkPath is one chunk reference discovered while walking the AST.
+type ChunkPath struct {
+	Path string
+	Kind Kind
+}
+
+// Extract parses source as JavaScript and returns every chunk path it can
+// find by walking the resulting AST. It never returns an error for input
+// that merely fails to parse as valid JS (minified bundles occasionally
+// confuse tolerant parsers) - in that case it returns a nil slice, and
+// ProcessWebpackFile falls back to its legacy regex patterns as it does for
+// any empty result.
+func Extract(source []byte) []ChunkPath {
+	ast, err := js.Parse(parse.NewInputBytes(source), js.Options{})
+	if err != nil {
+		return nil
+	}
+
+	v := &visitor{}
+	js.Walk(v, ast)
+	return v.found
+}
+
+type visitor struct {
+	found []ChunkPath
+}
+
+func (v *visitor) Enter(n js.INode) js.IVisitor {
+	switch node := n.(type) {
+	case *js.ObjectExpr:
+		for _, prop := range node.List {
+			v.collectManifestLiteral(prop.Value)
+		}
+	case *js.ArrayExpr:
+		for _, el := range node.List {
+			v.collectManifestLiteral(el.Value)
+		}
+	case *js.CallExpr:
+		if path, ok := dynamicImportArg(node); ok {
+			v.found = append(v.found, ChunkPath{Path: path, Kind: KindDynamicImport})
+		}
+	case *js.BinaryExpr:
+		if node.Op == js.AddToken {
+			if path, ok := concatenatedPath(node); ok {
+				v.found = append(v.found, ChunkPath{Path: path, Kind: KindConcat})
+			}
+		}
+	}
+	return v
+}
+
+func (v *visitor) Exit(n js.INode) {}
+
+// collectManifestLiteral reports e as KindManifest when it's a bare string
+// literal ending in .js. It's only called for an object property's or array
+// element's value, not for every string literal in the tree - a literal
+// anywhere else (an operand of a concatenation, a plain variable
+// initializer) isn't a manifest entry and is handled, if at all, by
+// concatenatedPath instead.
+func (v *visitor) collectManifestLiteral(e js.IExpr) {
+	lit, ok := e.(*js.LiteralExpr)
+	if !ok || lit.TokenType != js.StringToken {
+		return
+	}
+	if s := unquote(string(lit.Data)); looksLikeChunkPath(s) {
+		v.found = append(v.found, ChunkPath{Path: s, Kind: KindManifest})
+	}
+}
+
+// dynamicImportArg reports whether call is `import("...")` and returns its
+// literal string argument.
+func dynamicImportArg(call *js.CallExpr) (string, bool) {
+	callee, ok := call.X.(*js.LiteralExpr)
+	if !ok || callee.TokenType != js.ImportToken {
+		return "", false
+	}
+	if len(call.Args.List) == 0 {
+		return "", false
+	}
+	lit, ok := call.Args.List[0].Value.(*js.LiteralExpr)
+	if !ok || lit.TokenType != js.StringToken {
+		return "", false
+	}
+	return unquote(string(lit.Data)), true
+}
+
+// concatenatedPath reconstructs runtime string concatenation such as
+// `"static/chunks/" + "main" + ".js"` by flattening the binary expression
+// tree and joining its operands - but only when every operand is itself a
+// string literal. A concatenation with a non-literal operand (an
+// identifier, a member expression, a ternary) has no fixed path to report:
+// that covers both cases where the expression isn't a chunk reference at
+// all - e.g. analytics trackers commonly build a URL as
+// `(isSecure ? "https://ssl" : "http://www") + ".example.com/analytics.js"`
+// - and the common bundler shape `publicPath + "static/..." + hash + ".js"`,
+// which genuinely is one but can't be resolved without evaluating
+// `publicPath`/`hash` at runtime. Reporting a fragment with the identifier
+// operands silently dropped would be actively misleading, so any non-literal
+// operand bails the whole match instead; that dynamic-hash shape is left to
+// the legacy regex patterns or a "dictProduct"/"auFunction" Template extractor.
+func concatenatedPath(expr *js.BinaryExpr) (string, bool) {
+	var parts []string
+	literalOnly := true
+	var walk func(js.IExpr)
+	walk = func(e js.IExpr) {
+		switch n := e.(type) {
+		case *js.BinaryExpr:
+			if n.Op == js.AddToken {
+				walk(n.X)
+				walk(n.Y)
+				return
+			}
+			literalOnly = false
+		case *js.LiteralExpr:
+			if n.TokenType != js.StringToken {
+				literalOnly = false
+				return
+			}
+			parts = append(parts, unquote(string(n.Data)))
+		default:
+			literalOnly = false
+		}
+	}
+	walk(expr)
+
+	if !literalOnly {
+		return "", false
+	}
+	joined := strings.Join(parts, "")
+	if !looksLikeChunkPath(joined) {
+		return "", false
+	}
+	return joined, true
+}
+
+// looksLikeChunkPath filters string literals down to ones worth reporting:
+// it must look like a path ending in .js, optionally with a query/hash.
+func looksLikeChunkPath(s string) bool {
+	if s == "" {
+		return false
+	}
+	trimmed := strings.SplitN(s, "?", 2)[0]
+	return strings.HasSuffix(trimmed, ".js")
+}
+
+// unquote strips the surrounding quote characters a string literal token
+// carries (tdewolff/parse/v2/js returns raw source text, quotes included).
+func unquote(raw string) string {
+	if len(raw) >= 2 {
+		first, last := raw[0], raw[len(raw)-1]
+		if (first == '"' || first == '\'' || first == '`') && first == last {
+			return raw[1 : len(raw)-1]
+		}
+	}
+	return raw
+}
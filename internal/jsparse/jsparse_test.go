@@ -0,0 +1,66 @@
+package jsparse
+
+import "testing"
+
+func TestExtractManifestRequiresObjectOrArrayContext(t *testing.T) {
+	// A bare string literal that happens to end in .js but isn't a value
+	// inside an object/array literal (here, the tail operand of a runtime
+	// string concatenation built from a non-literal ternary) must not be
+	// reported at all: it has no fixed path and isn't a manifest entry.
+	src := `var ga = (isSecure ? "https://ssl" : "http://www") + ".google-analytics.com/analytics.js";`
+	chunks := Extract([]byte(src))
+	if len(chunks) != 0 {
+		t.Fatalf("expected no chunks for a non-literal concatenation, got %v", chunks)
+	}
+}
+
+func TestExtractManifestObjectLiteral(t *testing.T) {
+	src := `var chunks = {1027: "static/chunks/1027.4b26d002.js", 142: "static/chunks/142.js"};`
+	chunks := Extract([]byte(src))
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 manifest chunks, got %d: %v", len(chunks), chunks)
+	}
+	for _, c := range chunks {
+		if c.Kind != KindManifest {
+			t.Errorf("expected KindManifest, got %q for %q", c.Kind, c.Path)
+		}
+	}
+}
+
+func TestExtractManifestArrayLiteral(t *testing.T) {
+	src := `var chunks = ["static/chunks/a.js", "static/chunks/b.js"];`
+	chunks := Extract([]byte(src))
+	if len(chunks) != 2 {
+		t.Fatalf("expected 2 manifest chunks, got %d: %v", len(chunks), chunks)
+	}
+}
+
+func TestExtractConcatRequiresAllLiteralOperands(t *testing.T) {
+	src := `var url = publicPath + "static/chunks/" + hash + ".js";`
+	chunks := Extract([]byte(src))
+	if len(chunks) != 0 {
+		t.Fatalf("expected no chunks when an operand is an identifier, got %v", chunks)
+	}
+}
+
+func TestExtractConcatAllLiteralOperands(t *testing.T) {
+	src := `var url = "static/chunks/" + "main" + ".js";`
+	chunks := Extract([]byte(src))
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 concat chunk, got %d: %v", len(chunks), chunks)
+	}
+	if chunks[0].Kind != KindConcat || chunks[0].Path != "static/chunks/main.js" {
+		t.Errorf("unexpected chunk: %+v", chunks[0])
+	}
+}
+
+func TestExtractDynamicImport(t *testing.T) {
+	src := `import("./lazy-chunk.js").then(m => m.default());`
+	chunks := Extract([]byte(src))
+	if len(chunks) != 1 {
+		t.Fatalf("expected 1 dynamic-import chunk, got %d: %v", len(chunks), chunks)
+	}
+	if chunks[0].Kind != KindDynamicImport || chunks[0].Path != "./lazy-chunk.js" {
+		t.Errorf("unexpected chunk: %+v", chunks[0])
+	}
+}
@@ -0,0 +1,221 @@
+package subjs
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// BundlerAdapter knows how to recognize one bundler's output and pull the
+// chunk paths it references out of it. SubJS tries each registered adapter
+// in order and runs Extract on the first one whose Detect matches, so
+// adding support for a new bundler is a matter of writing and registering
+// an adapter rather than teaching the core crawl loop another special case.
+type BundlerAdapter interface {
+	Name() string
+	Detect(url string, body []byte) bool
+	Extract(url string, body []byte) []string
+}
+
+// hashedChunkNamePattern matches the content-hashed filenames Vite, Parcel,
+// Rollup and esbuild all use for split chunks, e.g. "index-4f3a9c21.js".
+var hashedChunkNamePattern = regexp.MustCompile(`-[0-9a-fA-F]{8,}\.js(\?.*)?$`)
+
+// looksLikeBundle is a cheap, URL-only pre-filter used to decide whether a
+// discovered script is worth fetching at all before any adapter gets a
+// chance to inspect its body.
+func looksLikeBundle(u string) bool {
+	if isWebpackBundle(u) {
+		return true
+	}
+	return strings.Contains(u, "/assets/") || strings.Contains(u, "chunk-") || hashedChunkNamePattern.MatchString(u)
+}
+
+// defaultAdapters returns the built-in adapter set in detection priority
+// order: Next.js first since its chunk manifests are otherwise
+// indistinguishable from plain webpack output, then the other
+// bundler-specific adapters, with the generic webpack/jsparse adapter last
+// as a catch-all.
+func defaultAdapters(s *SubJS) []BundlerAdapter {
+	return []BundlerAdapter{
+		&NextJSAdapter{s: s},
+		ViteAdapter{},
+		ParcelAdapter{},
+		RollupAdapter{},
+		&WebpackAdapter{s: s},
+	}
+}
+
+// RegisterAdapter adds a custom BundlerAdapter ahead of the built-in ones,
+// so it gets first refusal on every discovered script.
+func (s *SubJS) RegisterAdapter(a BundlerAdapter) {
+	s.adapters = append([]BundlerAdapter{a}, s.adapters...)
+}
+
+// appendAdapter adds a BundlerAdapter after every adapter registered so far,
+// so it only gets a chance once nothing earlier in the list (built-in or
+// already-registered) has claimed the script. New uses this for the
+// templates it loads by default, since they're meant to extend detection
+// coverage, not pre-empt the built-in adapters they're modeled on.
+func (s *SubJS) appendAdapter(a BundlerAdapter) {
+	s.adapters = append(s.adapters, a)
+}
+
+// detectBundle returns the first registered adapter that claims url/body,
+// if any.
+func (s *SubJS) detectBundle(url string, body []byte) (BundlerAdapter, bool) {
+	for _, a := range s.adapters {
+		if a.Detect(url, body) {
+			return a, true
+		}
+	}
+	return nil, false
+}
+
+// collectWebpackChunks drains ProcessWebpackFile's channel-based output into
+// a slice, so the adapters below (which return a slice, per BundlerAdapter)
+// can wrap it without changing ProcessWebpackFile's existing signature.
+func collectWebpackChunks(emit func(chan string)) []string {
+	ch := make(chan string)
+	var out []string
+	done := make(chan struct{})
+	go func() {
+		for c := range ch {
+			out = append(out, c)
+		}
+		close(done)
+	}()
+	emit(ch)
+	close(ch)
+	<-done
+	return out
+}
+
+// WebpackAdapter is the generic catch-all: it runs ProcessWebpackFile, which
+// itself prefers the jsparse AST walk over the original regex patterns
+// (see Options.LegacyRegex).
+type WebpackAdapter struct{ s *SubJS }
+
+func (a *WebpackAdapter) Name() string { return "webpack" }
+
+func (a *WebpackAdapter) Detect(u string, body []byte) bool {
+	return isWebpackBundle(u) || bytes.Contains(body, []byte("webpackJsonp")) || bytes.Contains(body, []byte("__webpack_require__"))
+}
+
+func (a *WebpackAdapter) Extract(u string, body []byte) []string {
+	return collectWebpackChunks(func(ch chan string) {
+		a.s.ProcessWebpackFile(u, string(body), ch)
+	})
+}
+
+// NextJSAdapter handles Next.js's webpack flavor specifically: its chunk
+// manifests use the same regex shapes processWebpackFileRegex was written
+// for, and its chunk paths need the "_next/" prefix restored.
+type NextJSAdapter struct{ s *SubJS }
+
+func (a *NextJSAdapter) Name() string { return "nextjs" }
+
+func (a *NextJSAdapter) Detect(u string, body []byte) bool {
+	return strings.Contains(u, "_next/static") ||
+		bytes.Contains(body, []byte("__NEXT_DATA__")) ||
+		bytes.Contains(body, []byte("next/dist"))
+}
+
+func (a *NextJSAdapter) Extract(u string, body []byte) []string {
+	return collectWebpackChunks(func(ch chan string) {
+		a.s.ProcessWebpackFile(u, string(body), ch)
+	})
+}
+
+// viteAssetPattern matches Vite's hashed chunk/asset references, which it
+// emits either as static import specifiers or entries in a
+// __vite__mapDeps([...]) array alongside the dynamic import that uses them.
+var viteAssetPattern = regexp.MustCompile(`["'](/?assets/[\w.-]+\.js)["']`)
+
+// ViteAdapter recognizes Vite's build output.
+type ViteAdapter struct{}
+
+func (a ViteAdapter) Name() string { return "vite" }
+
+func (a ViteAdapter) Detect(u string, body []byte) bool {
+	return strings.Contains(u, "/assets/") && (bytes.Contains(body, []byte("__vite__mapDeps")) || viteAssetPattern.Match(body))
+}
+
+func (a ViteAdapter) Extract(u string, body []byte) []string {
+	baseURL, err := url.Parse(u)
+	if err != nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var out []string
+	for _, m := range viteAssetPattern.FindAllSubmatch(body, -1) {
+		resolved := resolveScriptURL(baseURL, string(m[1]))
+		if !seen[resolved] {
+			seen[resolved] = true
+			out = append(out, resolved)
+		}
+	}
+	return out
+}
+
+// parcelRequirePattern matches the require("./hashedName.js") calls Parcel's
+// runtime uses to pull in sibling chunks.
+var parcelRequirePattern = regexp.MustCompile(`require\(["']([^"']+\.js)["']\)`)
+
+// ParcelAdapter recognizes Parcel's runtime (parcelRequire / require.register).
+type ParcelAdapter struct{}
+
+func (a ParcelAdapter) Name() string { return "parcel" }
+
+func (a ParcelAdapter) Detect(_ string, body []byte) bool {
+	return bytes.Contains(body, []byte("parcelRequire")) || bytes.Contains(body, []byte("require.register"))
+}
+
+func (a ParcelAdapter) Extract(u string, body []byte) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, m := range parcelRequirePattern.FindAllSubmatch(body, -1) {
+		// Parcel's require() calls are relative to the chunk they appear
+		// in, not the site root - resolveAgainst honors that (and still
+		// handles an absolute or protocol-relative specifier correctly),
+		// where resolveScriptURL would root a "./foo.js" at "/foo.js".
+		resolved := resolveAgainst(u, string(m[1]))
+		if resolved != "" && !seen[resolved] {
+			seen[resolved] = true
+			out = append(out, resolved)
+		}
+	}
+	return out
+}
+
+// rollupFromPattern matches the `from "./chunk-XXXX.js"` specifiers Rollup
+// emits at the top of a chunk to import the other chunks it depends on.
+var rollupFromPattern = regexp.MustCompile(`from\s*["']([^"']+\.js)["']`)
+
+// RollupAdapter recognizes Rollup's (and esbuild-splitting's, which shares
+// the same ES module chunk-import shape) output.
+type RollupAdapter struct{}
+
+func (a RollupAdapter) Name() string { return "rollup" }
+
+func (a RollupAdapter) Detect(u string, body []byte) bool {
+	return strings.Contains(u, "chunk-") || bytes.Contains(body, []byte("System.register")) || rollupFromPattern.Match(body)
+}
+
+func (a RollupAdapter) Extract(u string, body []byte) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, m := range rollupFromPattern.FindAllSubmatch(body, -1) {
+		// A Rollup chunk's "from" specifiers are relative to that chunk's
+		// own directory (e.g. a chunk served from /build/ importing
+		// "./chunk-a1b2.js" means /build/chunk-a1b2.js), not the site
+		// root resolveScriptURL would assume.
+		resolved := resolveAgainst(u, string(m[1]))
+		if resolved != "" && !seen[resolved] {
+			seen[resolved] = true
+			out = append(out, resolved)
+		}
+	}
+	return out
+}
@@ -0,0 +1,108 @@
+package subjs
+
+import "testing"
+
+func TestViteAdapterDetectAndExtract(t *testing.T) {
+	a := ViteAdapter{}
+	body := []byte(`const deps = __vite__mapDeps([0,1]); import("/assets/chunk-a1b2c3d4.js");`)
+	url := "https://example.com/assets/index-4f3a9c21.js"
+
+	if !a.Detect(url, body) {
+		t.Fatal("expected ViteAdapter to detect its own output")
+	}
+	got := a.Extract(url, body)
+	if len(got) != 1 || got[0] != "https://example.com/assets/chunk-a1b2c3d4.js" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestViteAdapterDoesNotDetectUnrelatedAssets(t *testing.T) {
+	a := ViteAdapter{}
+	if a.Detect("https://example.com/assets/logo.png", []byte("not js at all")) {
+		t.Fatal("expected ViteAdapter to require a vite marker in the body")
+	}
+}
+
+func TestParcelAdapterResolvesRelativeToChunk(t *testing.T) {
+	a := ParcelAdapter{}
+	body := []byte(`parcelRequire.register("a1b2"); require("./b3c4.js");`)
+	url := "https://example.com/build/a1b2.js"
+
+	if !a.Detect(url, body) {
+		t.Fatal("expected ParcelAdapter to detect parcelRequire")
+	}
+	got := a.Extract(url, body)
+	if len(got) != 1 || got[0] != "https://example.com/build/b3c4.js" {
+		t.Fatalf("expected the sibling chunk resolved against /build/, got %v", got)
+	}
+}
+
+func TestRollupAdapterResolvesRelativeToChunk(t *testing.T) {
+	a := RollupAdapter{}
+	body := []byte(`import { x } from "./chunk-d4e5f6.js";`)
+	url := "https://example.com/build/entry.js"
+
+	if !a.Detect(url, body) {
+		t.Fatal("expected RollupAdapter to detect a from specifier")
+	}
+	got := a.Extract(url, body)
+	if len(got) != 1 || got[0] != "https://example.com/build/chunk-d4e5f6.js" {
+		t.Fatalf("expected the chunk resolved against /build/, got %v", got)
+	}
+}
+
+func TestNextJSAdapterDetectsNextData(t *testing.T) {
+	a := &NextJSAdapter{}
+	if !a.Detect("https://example.com/_next/static/chunks/main.js", nil) {
+		t.Fatal("expected NextJSAdapter to detect a _next/static URL")
+	}
+	if !a.Detect("https://example.com/main.js", []byte("window.__NEXT_DATA__ = {}")) {
+		t.Fatal("expected NextJSAdapter to detect __NEXT_DATA__ in the body")
+	}
+}
+
+func TestDefaultAdaptersTriesNextJSBeforeWebpack(t *testing.T) {
+	s := &SubJS{opts: &Options{}}
+	s.adapters = defaultAdapters(s)
+
+	body := []byte(`webpackJsonp([1], {});`)
+	adapter, ok := s.detectBundle("https://example.com/_next/static/chunks/main.js", body)
+	if !ok {
+		t.Fatal("expected an adapter to claim this URL")
+	}
+	if adapter.Name() != "nextjs" {
+		t.Fatalf("expected NextJSAdapter to win on a _next/static URL, got %q", adapter.Name())
+	}
+}
+
+func TestRegisterAdapterPrependsAheadOfBuiltins(t *testing.T) {
+	s := &SubJS{opts: &Options{}}
+	s.adapters = defaultAdapters(s)
+	s.RegisterAdapter(stubAdapter{name: "custom", detect: true})
+
+	adapter, ok := s.detectBundle("https://example.com/whatever.js", nil)
+	if !ok || adapter.Name() != "custom" {
+		t.Fatalf("expected the custom adapter registered via RegisterAdapter to win, got %v", adapter)
+	}
+}
+
+func TestAppendAdapterDoesNotPreemptBuiltins(t *testing.T) {
+	s := &SubJS{opts: &Options{}}
+	s.adapters = defaultAdapters(s)
+	s.appendAdapter(stubAdapter{name: "custom", detect: true})
+
+	body := []byte(`webpackJsonp([1], {});`)
+	adapter, ok := s.detectBundle("https://example.com/_next/static/chunks/main.js", body)
+	if !ok || adapter.Name() != "nextjs" {
+		t.Fatalf("expected a default-loaded adapter to never preempt NextJSAdapter, got %v", adapter)
+	}
+}
+
+type stubAdapter struct {
+	name   string
+	detect bool
+}
+
+func (s stubAdapter) Name() string                        { return s.name }
+func (s stubAdapter) Detect(_ string, _ []byte) bool      { return s.detect }
+func (s stubAdapter) Extract(_ string, _ []byte) []string { return nil }
@@ -0,0 +1,244 @@
+package subjs
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// crawlItem is one unit of crawl work: a URL to fetch, the URL that
+// referenced it (empty for seed URLs read from the input), and how many
+// hops it is from its seed.
+type crawlItem struct {
+	url    string
+	parent string
+	depth  int
+}
+
+// workQueue is an unbounded FIFO with an atomic in-flight counter. Workers
+// that discover more work push back onto the same queue they're draining
+// (recursive crawling re-enqueues every chunk a bundle references), so a
+// fixed-size buffered channel would risk deadlock once every worker is
+// blocked trying to push. pop() blocks until an item is available or the
+// counter has dropped to zero and the queue is closed, at which point every
+// worker unblocks and exits.
+type workQueue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	items   []crawlItem
+	pending int64
+	closed  bool
+}
+
+func newWorkQueue() *workQueue {
+	q := &workQueue{}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// beginProducer holds the queue open before any items have started being
+// pushed, so finishItem can't race it down to zero mid-scan.
+func (q *workQueue) beginProducer() {
+	atomic.AddInt64(&q.pending, 1)
+}
+
+// endProducer releases the token beginProducer took once the producer has
+// no more items to push.
+func (q *workQueue) endProducer() {
+	q.finishItem()
+}
+
+// push adds an item to the queue and marks it in-flight.
+func (q *workQueue) push(item crawlItem) {
+	atomic.AddInt64(&q.pending, 1)
+	q.mu.Lock()
+	q.items = append(q.items, item)
+	q.mu.Unlock()
+	q.cond.Signal()
+}
+
+// pop blocks until an item is available or the queue has been closed, in
+// which case it returns ok=false.
+func (q *workQueue) pop() (crawlItem, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if len(q.items) == 0 {
+		return crawlItem{}, false
+	}
+	item := q.items[0]
+	q.items = q.items[1:]
+	return item, true
+}
+
+// finishItem marks one in-flight item (or producer token) as done. Once no
+// work is pending anywhere - not queued and not still being processed - the
+// queue closes and every blocked pop() wakes up to exit.
+func (q *workQueue) finishItem() {
+	if atomic.AddInt64(&q.pending, -1) != 0 {
+		return
+	}
+	q.mu.Lock()
+	q.closed = true
+	q.cond.Broadcast()
+	q.mu.Unlock()
+}
+
+// processItem fetches item.url and extracts everything it references: if
+// the URL itself looks like a bundle, the first matching BundlerAdapter
+// extracts its chunks, and if it's (or serves) HTML its script/div tags are
+// scanned the same way the original single-pass fetch did.
+func (s *SubJS) processItem(item crawlItem, q *workQueue, results chan string) {
+	body, err := s.fetchBody(item.url)
+	if err != nil {
+		return
+	}
+
+	if adapter, ok := s.detectBundle(item.url, body); ok {
+		s.extractBundleChunks(item, adapter, body, q, results)
+	}
+	s.resolveSourceMap(item.url, body, results)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		return
+	}
+	parsedURL, err := url.Parse(item.url)
+	if err != nil {
+		return
+	}
+
+	doc.Find("script").Each(func(_ int, scriptTag *goquery.Selection) {
+		if js, exists := scriptTag.Attr("src"); exists && js != "" {
+			s.discover(item, resolveScriptURL(parsedURL, js), q, results)
+		}
+
+		// Find JS references in script tag content
+		r := regexp.MustCompile(`[(\w./:)]*js`)
+		for _, js := range r.FindAllString(scriptTag.Contents().Text(), -1) {
+			switch {
+			case strings.HasPrefix(js, "//"):
+				s.discover(item, fmt.Sprintf("%s:%s", parsedURL.Scheme, js), q, results)
+			case strings.HasPrefix(js, "/"):
+				s.discover(item, fmt.Sprintf("%s://%s%s", parsedURL.Scheme, parsedURL.Host, js), q, results)
+			}
+		}
+	})
+
+	doc.Find("div").Each(func(_ int, divTag *goquery.Selection) {
+		if js, exists := divTag.Attr("data-script-src"); exists && js != "" {
+			s.discover(item, resolveScriptURL(parsedURL, js), q, results)
+		}
+	})
+}
+
+// extractBundleChunks runs the detected BundlerAdapter over an
+// already-fetched bundle body and routes every chunk it finds through
+// discover, so chunks referenced by a chunk keep getting crawled instead of
+// only being reported.
+func (s *SubJS) extractBundleChunks(item crawlItem, adapter BundlerAdapter, body []byte, q *workQueue, results chan string) {
+	for _, chunk := range adapter.Extract(item.url, body) {
+		s.discover(item, chunk, q, results)
+	}
+}
+
+// discover reports a URL found while processing item (either as a graph
+// edge or a plain result line) and, when recursion is enabled and within
+// Options.Depth, pushes it back onto the queue so it gets fetched too.
+// childURL is checked against s.visited first, so a URL referenced from
+// more than one parent (a shared vendor/common bundle, say) is still only
+// reported - and fetched - once.
+//
+// When Options.Recursive is off, discover keeps the tool's original
+// single-hop behavior: a discovered URL that looks like a bundle is fetched
+// and run through the matching BundlerAdapter once, but anything that
+// bundle itself references is only reported, not followed. Those
+// second-level chunks are reported through reportDiscovery rather than
+// written to results directly, so they get the same visited-dedup and
+// graph/plain-output handling as every other discovered URL - two sibling
+// bundles that both reference the same second-level chunk must still only
+// report it once, and Options.OutputFormat == "graph" must still see it as
+// an edge instead of silently dropping it.
+func (s *SubJS) discover(item crawlItem, childURL string, q *workQueue, results chan string) {
+	if !s.reportDiscovery(item.url, childURL, results) {
+		return
+	}
+
+	if s.opts.Recursive {
+		if s.opts.Depth > 0 && item.depth+1 > s.opts.Depth {
+			return
+		}
+		q.push(crawlItem{url: childURL, parent: item.url, depth: item.depth + 1})
+		return
+	}
+
+	if !looksLikeBundle(childURL) && !s.opts.SourceMaps {
+		return
+	}
+	childBody, err := s.fetchBody(childURL)
+	if err != nil {
+		return
+	}
+	if adapter, ok := s.detectBundle(childURL, childBody); ok {
+		for _, chunk := range adapter.Extract(childURL, childBody) {
+			s.reportDiscovery(childURL, chunk, results)
+		}
+	}
+	s.resolveSourceMap(childURL, childBody, results)
+}
+
+// reportDiscovery records that childURL was found via parentURL - as a
+// graph edge when Options.OutputFormat == "graph", otherwise as a plain
+// result line - the first time it's seen, and reports whether this call was
+// the first. s.visited is the single choke point both callers (discover's
+// own childURL and its non-recursive one-hop fallback's second-level
+// chunks) go through, so a URL reachable more than one way is still
+// reported exactly once.
+func (s *SubJS) reportDiscovery(parentURL, childURL string, results chan string) bool {
+	if _, loaded := s.visited.LoadOrStore(childURL, true); loaded {
+		return false
+	}
+	if s.opts.OutputFormat == "graph" {
+		s.graph.addEdge(parentURL, childURL)
+	} else {
+		results <- childURL
+	}
+	return true
+}
+
+// graphStore accumulates the adjacency list for Options.OutputFormat ==
+// "graph": which URL pulled in which child URLs.
+type graphStore struct {
+	mu  *sync.Mutex
+	adj map[string][]string
+}
+
+func newGraphStore() graphStore {
+	return graphStore{mu: &sync.Mutex{}, adj: make(map[string][]string)}
+}
+
+func (g graphStore) addEdge(parent, child string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.adj[parent] = append(g.adj[parent], child)
+}
+
+// writeGraph prints the accumulated adjacency list as a single JSON object.
+func (s *SubJS) writeGraph() error {
+	s.graph.mu.Lock()
+	defer s.graph.mu.Unlock()
+	out, err := json.MarshalIndent(s.graph.adj, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal dependency graph: %s", err)
+	}
+	fmt.Println(string(out))
+	return nil
+}
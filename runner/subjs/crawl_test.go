@@ -0,0 +1,234 @@
+package subjs
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestWorkQueuePushPop(t *testing.T) {
+	q := newWorkQueue()
+	q.push(crawlItem{url: "a.js"})
+	item, ok := q.pop()
+	if !ok || item.url != "a.js" {
+		t.Fatalf("got %+v, ok=%v", item, ok)
+	}
+}
+
+func TestWorkQueueClosesWhenPendingReachesZero(t *testing.T) {
+	q := newWorkQueue()
+	q.beginProducer()
+	q.push(crawlItem{url: "a.js"})
+	q.endProducer()
+
+	item, ok := q.pop()
+	if !ok || item.url != "a.js" {
+		t.Fatalf("got %+v, ok=%v", item, ok)
+	}
+	q.finishItem()
+
+	if _, ok := q.pop(); ok {
+		t.Fatal("expected pop to report the queue closed once pending hits zero")
+	}
+}
+
+// TestWorkQueueConcurrentPushPop exercises the shared pending counter and
+// condvar under -race: every item pushed must be popped by exactly one
+// worker, with no item lost or duplicated, however the pushes/pops
+// interleave.
+func TestWorkQueueConcurrentPushPop(t *testing.T) {
+	const n = 200
+	q := newWorkQueue()
+
+	q.beginProducer()
+	for i := 0; i < n; i++ {
+		q.push(crawlItem{url: fmt.Sprintf("chunk-%d.js", i)})
+	}
+	q.endProducer()
+
+	var mu sync.Mutex
+	seen := make(map[string]bool, n)
+	var workers sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				item, ok := q.pop()
+				if !ok {
+					return
+				}
+				mu.Lock()
+				seen[item.url] = true
+				mu.Unlock()
+				q.finishItem()
+			}
+		}()
+	}
+	workers.Wait()
+
+	if len(seen) != n {
+		t.Fatalf("expected %d unique items popped exactly once, got %d", n, len(seen))
+	}
+}
+
+// TestDiscoverDedupesVisitedURLs guards the bug chunk0-3 fixed: the visited
+// set must be shared across every caller of discover (standing in for
+// concurrent workers), not a per-goroutine map, so a child URL reachable
+// from more than one parent is reported and enqueued only once.
+func TestDiscoverDedupesVisitedURLs(t *testing.T) {
+	s := &SubJS{opts: &Options{Recursive: true}}
+	q := newWorkQueue()
+	results := make(chan string, 10)
+	item := crawlItem{url: "https://example.com/a.js"}
+
+	s.discover(item, "https://example.com/shared.js", q, results)
+	s.discover(item, "https://example.com/shared.js", q, results)
+
+	if len(q.items) != 1 {
+		t.Fatalf("expected shared.js enqueued exactly once, got %d items", len(q.items))
+	}
+	close(results)
+	var got []string
+	for r := range results {
+		got = append(got, r)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected shared.js reported exactly once, got %v", got)
+	}
+}
+
+// TestDiscoverRecursiveRespectsDepth checks that a recursive crawl stops
+// enqueueing once it would exceed Options.Depth, while still reporting the
+// URL it found.
+func TestDiscoverRecursiveRespectsDepth(t *testing.T) {
+	s := &SubJS{opts: &Options{Recursive: true, Depth: 1}}
+	q := newWorkQueue()
+	results := make(chan string, 10)
+
+	parent := crawlItem{url: "https://example.com/a.js", depth: 0}
+	s.discover(parent, "https://example.com/b.js", q, results)
+	if len(q.items) != 1 || q.items[0].depth != 1 {
+		t.Fatalf("expected b.js enqueued at depth 1, got %+v", q.items)
+	}
+
+	child := crawlItem{url: "https://example.com/b.js", depth: 1}
+	s.discover(child, "https://example.com/c.js", q, results)
+	if len(q.items) != 1 {
+		t.Fatalf("expected depth limit to stop further recursion, got %d items", len(q.items))
+	}
+
+	close(results)
+	var got []string
+	for r := range results {
+		got = append(got, r)
+	}
+	if len(got) != 2 || got[0] != "https://example.com/b.js" || got[1] != "https://example.com/c.js" {
+		t.Fatalf("expected both b.js and c.js reported regardless of depth, got %v", got)
+	}
+}
+
+// TestDiscoverNonRecursiveReportsOnlyOneHop checks that with Recursive off,
+// discover keeps its original single-hop behavior: the URL is reported but
+// never pushed back onto the queue.
+func TestDiscoverNonRecursiveReportsOnlyOneHop(t *testing.T) {
+	s := &SubJS{opts: &Options{}}
+	q := newWorkQueue()
+	results := make(chan string, 10)
+
+	parent := crawlItem{url: "https://example.com/a.js"}
+	s.discover(parent, "https://example.com/page.html", q, results)
+
+	if len(q.items) != 0 {
+		t.Fatalf("expected nothing enqueued when Recursive is off, got %d items", len(q.items))
+	}
+
+	close(results)
+	var got []string
+	for r := range results {
+		got = append(got, r)
+	}
+	if len(got) != 1 || got[0] != "https://example.com/page.html" {
+		t.Fatalf("expected page.html reported once, got %v", got)
+	}
+}
+
+// TestGraphStoreAddEdge checks the adjacency list accumulation
+// Options.OutputFormat == "graph" relies on.
+func TestGraphStoreAddEdge(t *testing.T) {
+	g := newGraphStore()
+	g.addEdge("parent.js", "child-a.js")
+	g.addEdge("parent.js", "child-b.js")
+
+	if got := g.adj["parent.js"]; len(got) != 2 || got[0] != "child-a.js" || got[1] != "child-b.js" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+// newRollupChunkServer serves a Rollup-style chunk importing one shared
+// sibling chunk, for the non-recursive one-hop fallback tests below.
+func newRollupChunkServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`import { x } from "./shared-chunk.js";`))
+	}))
+}
+
+// TestDiscoverOneHopFallbackDedupesGrandchildren guards the bug where the
+// non-recursive one-hop fallback wrote a bundle's second-level chunks
+// straight to results, bypassing s.visited: two sibling bundles that both
+// reference the same second-level chunk must still only report it once.
+func TestDiscoverOneHopFallbackDedupesGrandchildren(t *testing.T) {
+	srv := newRollupChunkServer(t)
+	defer srv.Close()
+
+	s := &SubJS{client: srv.Client(), opts: &Options{}, adapters: []BundlerAdapter{RollupAdapter{}}}
+	q := newWorkQueue()
+	results := make(chan string, 10)
+	parent := crawlItem{url: srv.URL + "/entry.js"}
+
+	s.discover(parent, srv.URL+"/chunk-a.js", q, results)
+	s.discover(parent, srv.URL+"/chunk-b.js", q, results)
+
+	close(results)
+	shared := srv.URL + "/shared-chunk.js"
+	count := 0
+	for r := range results {
+		if r == shared {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected %q reported exactly once across sibling bundles, got %d", shared, count)
+	}
+}
+
+// TestDiscoverOneHopFallbackAddsGraphEdgeForGrandchild guards the other half
+// of the same bug: with Options.OutputFormat == "graph" (a combination
+// that's valid without Recursive), the one-hop fallback's second-level
+// chunks must still become graph edges instead of being silently dropped.
+func TestDiscoverOneHopFallbackAddsGraphEdgeForGrandchild(t *testing.T) {
+	srv := newRollupChunkServer(t)
+	defer srv.Close()
+
+	s := &SubJS{
+		client:   srv.Client(),
+		opts:     &Options{OutputFormat: "graph"},
+		graph:    newGraphStore(),
+		adapters: []BundlerAdapter{RollupAdapter{}},
+	}
+	q := newWorkQueue()
+	results := make(chan string, 10)
+	parent := crawlItem{url: srv.URL + "/entry.js"}
+	childURL := srv.URL + "/chunk-a.js"
+
+	s.discover(parent, childURL, q, results)
+
+	shared := srv.URL + "/shared-chunk.js"
+	edges := s.graph.adj[childURL]
+	if len(edges) != 1 || edges[0] != shared {
+		t.Fatalf("expected a graph edge from %q to %q, got %v", childURL, shared, edges)
+	}
+}
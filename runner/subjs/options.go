@@ -0,0 +1,70 @@
+package subjs
+
+// Options controls the behavior of a SubJS run.
+type Options struct {
+	InputFile string
+	Workers   int
+	UserAgent string
+	Timeout   int
+
+	// SourceMaps enables source map discovery: every fetched JS URL is
+	// inspected for a sourceMappingURL directive, the referenced .map is
+	// fetched and parsed, and the original sources it points at are
+	// emitted alongside the JS URL itself.
+	SourceMaps bool
+
+	// OutputFormat selects how results are written. The zero value keeps
+	// the historical one-URL-per-line behavior; "sourcemap" groups
+	// {js_url, sourcemap_url, sources[]} objects instead; "graph" suppresses
+	// per-line output entirely and instead prints a single JSON adjacency
+	// list of {parent_url: [child_urls...]} once the crawl finishes
+	// (intended for use with Recursive); "jsonl" keeps one result per line
+	// but, for anything a Template extracts, tags each line with
+	// {template, extractor, url} instead of a bare URL.
+	OutputFormat string
+
+	// LegacyRegex forces ProcessWebpackFile back onto the original
+	// hand-written regex patterns instead of the jsparse AST walk. The AST
+	// pass is the default because it generalizes across bundlers; this
+	// flag exists as an escape hatch if it ever regresses on a bundle the
+	// regexes used to handle.
+	LegacyRegex bool
+
+	// Recursive feeds every chunk discovered in a bundle back into the
+	// crawl, so second-level chunks referenced by a first-level chunk are
+	// fetched and parsed too, instead of being reported but never visited.
+	Recursive bool
+
+	// Depth caps how many hops a recursive crawl will follow from a seed
+	// URL. Zero or negative means unlimited.
+	Depth int
+
+	// TemplatesDir is scanned at startup for *.yaml/*.yml extraction
+	// templates (see Template in template.go). Each one is registered as a
+	// BundlerAdapter after the built-ins, so community recipes for new
+	// frameworks can match once the built-in adapters (and any custom one
+	// registered via RegisterAdapter) have had a chance to. Leaving it unset
+	// doesn't disable template loading: New falls back to
+	// defaultTemplatesDir so the bundled templates/ directory still loads by
+	// default.
+	TemplatesDir string
+
+	// CacheDir, if set, persists every fetched response under this
+	// directory (see internal/cache) and, on a later run, revalidates it
+	// with If-None-Match/If-Modified-Since instead of re-fetching it cold.
+	// This is what lets repeated scans of the same URL list skip
+	// re-downloading chunks that haven't changed.
+	CacheDir string
+
+	// CacheTTL, in seconds, is how long a cached response is served
+	// without even a conditional revalidation request. Zero means every
+	// fetch is still revalidated against the server (but costs a
+	// lightweight 304 instead of a full re-download when unchanged).
+	CacheTTL int
+
+	// OfflineOnly answers every fetch purely from CacheDir, never touching
+	// the network; a URL with no cached entry yet fails. Pairs with
+	// CacheDir to diff today's chunk list against a previously cached run
+	// without a single live request.
+	OfflineOnly bool
+}
@@ -13,14 +13,39 @@ import (
 	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/marcostolosa/subjs/internal/cache"
+	"github.com/marcostolosa/subjs/internal/jsparse"
 )
 
 const version = `1.0.2`
 
+// defaultTemplatesDir is scanned the same as Options.TemplatesDir when the
+// latter is left unset, so the templates this tool ships with (see
+// templates/nextjs-webpack.yaml) are registered out of the box instead of
+// only when a caller explicitly points TemplatesDir at them. LoadTemplates
+// already tolerates a missing directory by returning an error New ignores,
+// so this is a no-op when running somewhere that directory doesn't exist.
+const defaultTemplatesDir = "templates"
+
 type SubJS struct {
 	client *http.Client
 	opts   *Options
+
+	// cache is non-nil when Options.CacheDir is set; fetchBody routes
+	// every request through it instead of client directly.
+	cache *cache.Cache
+
+	// visited guards URL dedup across all workers. It replaced a
+	// per-goroutine processedURLs map that let two workers fetch (and
+	// emit) the same URL whenever they happened to discover it
+	// independently.
+	visited sync.Map
+	graph   graphStore
+
+	// adapters are tried in order by detectBundle; RegisterAdapter prepends
+	// to it so custom adapters get first refusal, while appendAdapter (used
+	// for the templates New loads by default) adds to the end instead.
+	adapters []BundlerAdapter
 }
 
 func New(opts *Options) *SubJS {
@@ -28,7 +53,33 @@ func New(opts *Options) *SubJS {
 		Timeout:   time.Duration(opts.Timeout) * time.Second,
 		Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
 	}
-	return &SubJS{client: c, opts: opts}
+	s := &SubJS{client: c, opts: opts, graph: newGraphStore()}
+	s.adapters = defaultAdapters(s)
+
+	templatesDir := opts.TemplatesDir
+	if templatesDir == "" {
+		templatesDir = defaultTemplatesDir
+	}
+	// Templates loaded here are appended after the built-in adapters, not
+	// prepended via RegisterAdapter: the bundled templates/nextjs-webpack.yaml
+	// mirrors NextJSAdapter's own patterns, and registering it ahead of
+	// NextJSAdapter would make its bare "_next/static" URL match win over the
+	// AST-based WebpackAdapter/NextJSAdapter path for every real Next.js
+	// chunk, silently reverting chunk0-2's AST-first default. A caller that
+	// explicitly wants a template to take priority can still do so through
+	// RegisterAdapter.
+	if templates, err := LoadTemplates(templatesDir); err == nil {
+		for _, t := range templates {
+			t.s = s
+			s.appendAdapter(t)
+		}
+	}
+
+	if opts.CacheDir != "" {
+		s.cache = cache.New(opts.CacheDir, c, time.Duration(opts.CacheTTL)*time.Second, opts.OfflineOnly)
+	}
+
+	return s
 }
 
 func (s *SubJS) Run() error {
@@ -47,176 +98,152 @@ func (s *SubJS) Run() error {
 		defer input.Close()
 	}
 
-	// init channels
-	urls := make(chan string)
+	q := newWorkQueue()
 	results := make(chan string)
 
+	// setup output; "graph" mode prints a single JSON document once the
+	// crawl finishes instead of streaming one result per line.
+	var out sync.WaitGroup
+	out.Add(1)
+	go func() {
+		defer out.Done()
+		for result := range results {
+			if s.opts.OutputFormat != "graph" {
+				fmt.Println(result)
+			}
+		}
+	}()
+
 	// start workers
 	var w sync.WaitGroup
 	for i := 0; i < s.opts.Workers; i++ {
 		w.Add(1)
 		go func() {
-			s.fetch(urls, results)
-			w.Done()
+			defer w.Done()
+			for {
+				item, ok := q.pop()
+				if !ok {
+					return
+				}
+				s.processItem(item, q, results)
+				q.finishItem()
+			}
 		}()
 	}
-	// setup output
-	var out sync.WaitGroup
-	out.Add(1)
-	go func() {
-		for result := range results {
-			fmt.Println(result)
-		}
-		out.Done()
-	}()
+
+	// Hold the queue open with a producer token until every seed URL has
+	// been pushed, otherwise a worker could drain the queue and trip the
+	// zero-pending close before the scanner finishes feeding it.
+	q.beginProducer()
 	scan := bufio.NewScanner(input)
 	for scan.Scan() {
 		u := scan.Text()
-		if u != "" {
-			urls <- u
+		if u == "" {
+			continue
 		}
+		if _, loaded := s.visited.LoadOrStore(u, true); loaded {
+			continue
+		}
+		q.push(crawlItem{url: u})
 	}
-	close(urls)
+	q.endProducer()
+
 	w.Wait()
 	close(results)
 	out.Wait()
+
+	if s.opts.OutputFormat == "graph" {
+		return s.writeGraph()
+	}
 	return nil
 }
 
-func (s *SubJS) fetch(urls <-chan string, results chan string) {
-	// Create a set to track processed URLs
-	processedURLs := make(map[string]bool)
-
-	for u := range urls {
-		if processedURLs[u] {
-			continue // Skip already processed URLs
-		}
-		processedURLs[u] = true
-
-		req, err := http.NewRequest("GET", u, nil)
-		if err != nil {
-			continue
-		}
-		if s.opts.UserAgent != "" {
-			req.Header.Add("User-Agent", s.opts.UserAgent)
-		}
-		resp, err := s.client.Do(req)
-		if err != nil {
-			continue
-		}
-
-		// Read the complete response
-		body, err := ioutil.ReadAll(resp.Body)
-		resp.Body.Close()
-		if err != nil {
-			continue
-		}
-
-		// Try to parse as HTML
-		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
-		if err != nil {
-			continue
-		}
-
-		parsedURL, err := url.Parse(u)
-		if err != nil {
-			continue
-		}
-
-		// Process script tags - using scriptTag instead of s to avoid shadowing
-		doc.Find("script").Each(func(index int, scriptTag *goquery.Selection) {
-			js, exists := scriptTag.Attr("src")
-			if exists && js != "" {
-				// Resolve the URL
-				resolvedJS := resolveScriptURL(parsedURL, js)
-
-				// Report the script
-				if !processedURLs[resolvedJS] {
-					results <- resolvedJS
-					processedURLs[resolvedJS] = true
-
-					// Check if this looks like a webpack bundle
-					if isWebpackBundle(resolvedJS) {
-						// Fetch the webpack script
-						webpackReq, err := http.NewRequest("GET", resolvedJS, nil)
-						if err != nil {
-							return
-						}
-						if s.opts.UserAgent != "" {
-							webpackReq.Header.Add("User-Agent", s.opts.UserAgent)
-						}
-						webpackResp, err := s.client.Do(webpackReq)
-						if err != nil {
-							return
-						}
-
-						webpackBody, err := ioutil.ReadAll(webpackResp.Body)
-						webpackResp.Body.Close()
-						if err != nil {
-							return
-						}
-
-						// Process the webpack file to extract chunk references
-						s.ProcessWebpackFile(resolvedJS, string(webpackBody), results)
-					}
-				}
-			}
-
-			// Find JS references in script tag content
-			r := regexp.MustCompile(`[(\w./:)]*js`)
-			matches := r.FindAllString(scriptTag.Contents().Text(), -1)
-			for _, js := range matches {
-				if strings.HasPrefix(js, "//") {
-					js := fmt.Sprintf("%s:%s", parsedURL.Scheme, js)
-					if !processedURLs[js] {
-						results <- js
-						processedURLs[js] = true
-					}
-				} else if strings.HasPrefix(js, "/") {
-					js := fmt.Sprintf("%s://%s%s", parsedURL.Scheme, parsedURL.Host, js)
-					if !processedURLs[js] {
-						results <- js
-						processedURLs[js] = true
-					}
-				}
-			}
-		})
-
-		// Process div tags with data-script-src attribute - using divTag instead of s
-		doc.Find("div").Each(func(index int, divTag *goquery.Selection) {
-			js, exists := divTag.Attr("data-script-src")
-			if exists && js != "" {
-				resolvedJS := resolveScriptURL(parsedURL, js)
-				if !processedURLs[resolvedJS] {
-					results <- resolvedJS
-					processedURLs[resolvedJS] = true
-				}
-			}
-		})
+// fetchBody issues a GET against u and returns the full response body. It
+// centralizes the request setup (user agent header, client reuse) shared by
+// the webpack chunk fetch and the source map fetch, and, when Options.CacheDir
+// is set, is the single place that routes those fetches through s.cache
+// instead of the client directly.
+func (s *SubJS) fetchBody(u string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if s.opts.UserAgent != "" {
+		req.Header.Add("User-Agent", s.opts.UserAgent)
 	}
+	if s.cache != nil {
+		return s.cache.Get(req)
+	}
+	if s.opts.OfflineOnly {
+		return nil, fmt.Errorf("offline: no CacheDir configured to serve %s from", u)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
 }
 
-// ProcessWebpackFile extracts all JavaScript chunk paths from a webpack bundle
+// ProcessWebpackFile extracts all JavaScript chunk paths referenced by a
+// fetched bundle. It's a thin adapter: the jsparse AST walk runs first
+// because it isn't tied to one bundler's output shape, and the original
+// regex patterns only run as a fallback, either because Options.LegacyRegex
+// forces them or because the AST pass found nothing (e.g. the content
+// wasn't valid JS).
 func (s *SubJS) ProcessWebpackFile(webpackURL string, content string, results chan string) {
 	baseURL, err := url.Parse(webpackURL)
 	if err != nil {
 		return
 	}
 
-	// Track processed URLs to avoid duplicates
-	processedPaths := make(map[string]bool)
-
-	// Ensure path has _next/ prefix if not already present
-	ensureNextPrefix := func(path string) string {
-		if !strings.HasPrefix(path, "/_next/") && !strings.HasPrefix(path, "_next/") {
-			if strings.HasPrefix(path, "/") {
-				return "/_next" + path
+	if !s.opts.LegacyRegex {
+		chunks := jsparse.Extract([]byte(content))
+		if len(chunks) > 0 {
+			emitted := make(map[string]bool, len(chunks))
+			for _, c := range chunks {
+				resolvedURL := resolveScriptURL(baseURL, ensureNextPrefixIfWebpack(c.Path))
+				if !emitted[resolvedURL] {
+					results <- resolvedURL
+					emitted[resolvedURL] = true
+				}
 			}
-			return "/_next/" + path
+			return
 		}
-		return path
 	}
 
+	s.processWebpackFileRegex(baseURL, content, results)
+}
+
+// ensureNextPrefixIfWebpack applies the Next.js "_next/" chunk convention to
+// paths that look like they came from a Next.js bundle (i.e. under
+// static/chunks), leaving paths from other bundlers untouched.
+func ensureNextPrefixIfWebpack(path string) string {
+	if strings.Contains(path, "static/chunks") {
+		return ensureNextPrefix(path)
+	}
+	return path
+}
+
+// ensureNextPrefix has file scope beyond processWebpackFileRegex because
+// ensureNextPrefixIfWebpack also needs it.
+func ensureNextPrefix(path string) string {
+	if !strings.HasPrefix(path, "/_next/") && !strings.HasPrefix(path, "_next/") {
+		if strings.HasPrefix(path, "/") {
+			return "/_next" + path
+		}
+		return "/_next/" + path
+	}
+	return path
+}
+
+// processWebpackFileRegex is the original hand-written pattern matching for
+// Next.js webpack chunk manifests. It only runs when jsparse is disabled or
+// comes up empty; see Options.LegacyRegex.
+func (s *SubJS) processWebpackFileRegex(baseURL *url.URL, content string, results chan string) {
+	// Track processed URLs to avoid duplicates
+	processedPaths := make(map[string]bool)
+
 	// Pattern 1: Extract direct chunk references
 	// Example: a.u=e=>2986===e?"static/chunks/2986-2488e3e4a13aed5b.js"
 	directChunkPattern := regexp.MustCompile(`(\d+)===e\?"([^"]+)"`)
@@ -0,0 +1,199 @@
+package subjs
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// sourceMappingURLPattern matches the trailing directive bundlers append to
+// point a debugger at the original sources, e.g.
+// //# sourceMappingURL=main.js.map or //@ sourceMappingURL=main.js.map.
+var sourceMappingURLPattern = regexp.MustCompile(`//[#@]\s*sourceMappingURL=(\S+)`)
+
+// maxSourceMappingMarkerLookback bounds how far before a "sourceMappingURL="
+// match we'll look for its "//#"/"//@" marker. It only limits how far back
+// we search for the marker, never how much of the match itself (the URL or,
+// for an inline data: directive, its base64 payload) we capture - that
+// payload is routinely larger than this and must be read in full.
+const maxSourceMappingMarkerLookback = 2048
+
+// SourceMapResult is emitted in place of a bare URL when
+// Options.OutputFormat is "sourcemap": it groups a JS file together with
+// the map that describes it and the original sources the map resolves to.
+type SourceMapResult struct {
+	JSURL        string   `json:"js_url"`
+	SourceMapURL string   `json:"sourcemap_url"`
+	Sources      []string `json:"sources"`
+}
+
+// sourceMapFile mirrors the subset of the source map v3 spec we care about.
+type sourceMapFile struct {
+	Version    int      `json:"version"`
+	Sources    []string `json:"sources"`
+	SourceRoot string   `json:"sourceRoot"`
+}
+
+// resolveSourceMap looks for a sourceMappingURL directive in jsBody and
+// emits the original sources it lists (or a single grouped
+// SourceMapResult, depending on Options.OutputFormat). The map itself
+// comes either from fetching a relative/absolute URL or, for an inline
+// `data:` directive, from decoding the directive in place.
+func (s *SubJS) resolveSourceMap(jsURL string, jsBody []byte, results chan string) {
+	if !s.opts.SourceMaps {
+		return
+	}
+
+	mapRef := findSourceMappingURL(jsBody)
+	if mapRef == "" {
+		return
+	}
+
+	if strings.HasPrefix(mapRef, "data:") {
+		mapBody, ok := decodeDataURLSourceMap(mapRef)
+		if !ok {
+			return
+		}
+		s.emitSourceMap(jsURL, "inline", mapBody, results)
+		return
+	}
+
+	mapURL := resolveAgainst(jsURL, mapRef)
+	if mapURL == "" {
+		return
+	}
+
+	mapBody, err := s.fetchBody(mapURL)
+	if err != nil {
+		return
+	}
+	s.emitSourceMap(jsURL, mapURL, mapBody, results)
+}
+
+// emitSourceMap parses mapBody as a source map and reports the original
+// sources it lists, either as plain URLs or (Options.OutputFormat ==
+// "sourcemap") as a single grouped SourceMapResult. mapURL is "inline" for
+// a map decoded from a data: directive rather than fetched.
+func (s *SubJS) emitSourceMap(jsURL, mapURL string, mapBody []byte, results chan string) {
+	var sm sourceMapFile
+	if err := json.Unmarshal(mapBody, &sm); err != nil {
+		return
+	}
+
+	sources := make([]string, 0, len(sm.Sources))
+	for _, src := range sm.Sources {
+		sources = append(sources, resolveOriginalSource(jsURL, sm.SourceRoot, src))
+	}
+
+	if s.opts.OutputFormat == "sourcemap" {
+		out, err := json.Marshal(SourceMapResult{JSURL: jsURL, SourceMapURL: mapURL, Sources: sources})
+		if err != nil {
+			return
+		}
+		results <- string(out)
+		return
+	}
+
+	for _, src := range sources {
+		results <- src
+	}
+}
+
+// decodeDataURLSourceMap decodes an inline sourceMappingURL directive, e.g.
+// data:application/json;charset=utf-8;base64,eyJ2ZXJzaW9uIjoz... Non-base64
+// data URLs (the payload is percent-encoded JSON directly) are supported
+// too, since both forms appear in the wild.
+func decodeDataURLSourceMap(ref string) ([]byte, bool) {
+	comma := strings.IndexByte(ref, ',')
+	if comma == -1 {
+		return nil, false
+	}
+	meta, payload := ref[len("data:"):comma], ref[comma+1:]
+
+	if !strings.Contains(meta, "base64") {
+		unescaped, err := url.QueryUnescape(payload)
+		if err != nil {
+			return nil, false
+		}
+		return []byte(unescaped), true
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+	return decoded, true
+}
+
+// findSourceMappingURL returns the directive's argument, or "" if none is
+// present in body. It locates the "sourceMappingURL=" token first and only
+// bounds how far back it looks for the "//#"/"//@" marker that must precede
+// it - not how much it captures afterward, since an inline data: directive's
+// base64 payload is routinely many kilobytes and truncating it would cut the
+// directive's own prefix off the front of the match.
+func findSourceMappingURL(body []byte) string {
+	idx := bytes.LastIndex(body, []byte("sourceMappingURL="))
+	if idx == -1 {
+		return ""
+	}
+
+	lookbackStart := idx - maxSourceMappingMarkerLookback
+	if lookbackStart < 0 {
+		lookbackStart = 0
+	}
+	window := body[lookbackStart:]
+
+	matches := sourceMappingURLPattern.FindAllSubmatch(window, -1)
+	if len(matches) == 0 {
+		return ""
+	}
+	return string(matches[len(matches)-1][1])
+}
+
+// resolveAgainst resolves ref (absolute, relative, or protocol-relative)
+// against baseURL, the URL the directive was found in.
+func resolveAgainst(baseURL, ref string) string {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return ""
+	}
+	parsedRef, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	return base.ResolveReference(parsedRef).String()
+}
+
+// resolveOriginalSource turns a source map `sources` entry (commonly a
+// webpack://<project>/./src/Foo.tsx style path) into a URL rooted at the
+// site the bundle was served from, so it can be fetched like any other
+// discovered path.
+func resolveOriginalSource(jsURL, sourceRoot, src string) string {
+	clean := src
+	if idx := strings.Index(clean, "webpack://"); idx != -1 {
+		clean = clean[idx+len("webpack://"):]
+		// Strip the leading project/namespace segment webpack inserts,
+		// e.g. webpack://my-app/./src/Foo.tsx -> ./src/Foo.tsx
+		if slash := strings.Index(clean, "/"); slash != -1 {
+			clean = clean[slash+1:]
+		}
+	}
+	clean = strings.TrimPrefix(clean, "./")
+
+	if sourceRoot != "" {
+		clean = strings.TrimSuffix(sourceRoot, "/") + "/" + clean
+	}
+	if !strings.HasPrefix(clean, "/") {
+		clean = "/" + clean
+	}
+
+	base, err := url.Parse(jsURL)
+	if err != nil {
+		return clean
+	}
+	return fmt.Sprintf("%s://%s%s", base.Scheme, base.Host, clean)
+}
@@ -0,0 +1,63 @@
+package subjs
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFindSourceMappingURLPlain(t *testing.T) {
+	body := []byte("console.log(1);\n//# sourceMappingURL=main.js.map\n")
+	if got := findSourceMappingURL(body); got != "main.js.map" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestFindSourceMappingURLLargeInlinePayload(t *testing.T) {
+	// A realistic inline data: directive's base64 payload is routinely many
+	// kilobytes - well past maxSourceMappingMarkerLookback - and must not
+	// truncate the "sourceMappingURL=" prefix off the front of the match.
+	payload := strings.Repeat("A", 10*maxSourceMappingMarkerLookback)
+	body := []byte("console.log(1);\n//# sourceMappingURL=data:application/json;base64," + payload)
+
+	got := findSourceMappingURL(body)
+	want := "data:application/json;base64," + payload
+	if got != want {
+		t.Fatalf("expected the full inline payload to survive, got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+func TestFindSourceMappingURLNone(t *testing.T) {
+	if got := findSourceMappingURL([]byte("console.log(1);")); got != "" {
+		t.Fatalf("expected no match, got %q", got)
+	}
+}
+
+func TestDecodeDataURLSourceMapBase64(t *testing.T) {
+	ref := "data:application/json;charset=utf-8;base64,eyJ2ZXJzaW9uIjozfQ=="
+	body, ok := decodeDataURLSourceMap(ref)
+	if !ok {
+		t.Fatal("expected decode to succeed")
+	}
+	if string(body) != `{"version":3}` {
+		t.Fatalf("got %q", body)
+	}
+}
+
+func TestDecodeDataURLSourceMapPercentEncoded(t *testing.T) {
+	ref := `data:application/json,%7B%22version%22%3A3%7D`
+	body, ok := decodeDataURLSourceMap(ref)
+	if !ok {
+		t.Fatal("expected decode to succeed")
+	}
+	if string(body) != `{"version":3}` {
+		t.Fatalf("got %q", body)
+	}
+}
+
+func TestResolveOriginalSourceStripsWebpackNamespace(t *testing.T) {
+	got := resolveOriginalSource("https://example.com/static/main.js", "", "webpack://my-app/./src/Foo.tsx")
+	want := "https://example.com/src/Foo.tsx"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,250 @@
+package subjs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Template is an extraction recipe loaded from a YAML file in
+// Options.TemplatesDir. It implements BundlerAdapter; New registers each
+// loaded Template via appendAdapter, after the built-in adapters, so it only
+// claims a script none of them (or any RegisterAdapter adapter) detected.
+type Template struct {
+	TemplateName string               `yaml:"name"`
+	Match        TemplateMatch        `yaml:"match"`
+	Extractors   []*TemplateExtractor `yaml:"extractors"`
+
+	// s is set by New (not LoadTemplates) so Extract can check Options.OutputFormat;
+	// it mirrors the pattern WebpackAdapter and NextJSAdapter already use
+	// to reach back into the owning SubJS.
+	s *SubJS
+}
+
+// TemplateMatch is the predicate a Template runs against a discovered
+// script before its Extractors are tried. Both fields are optional; a
+// Template with neither set never matches.
+type TemplateMatch struct {
+	// URLContains, if set, must appear in the script's URL.
+	URLContains string `yaml:"urlContains"`
+	// BodyContains, if set, must ALL appear in the response body.
+	BodyContains []string `yaml:"bodyContains"`
+}
+
+// TemplateExtractor is one named rule within a Template. Kind selects how
+// Regex's capture groups turn into chunk paths:
+//
+//   - "" (the default) takes Group verbatim: the path is the literal text
+//     of that capture group.
+//   - "dictProduct" expects Regex to capture a base path plus two JS object
+//     literals (an id map and a hash map, per webpack's split-chunk
+//     manifest shape) and emits the cross product of the two, the same way
+//     processWebpackFileRegex's complexPattern does.
+//   - "auFunction" expects Regex to capture the body of webpack's a.u
+//     chunk-id function and applies the fixed `(\d+)===e\?"..."` ternary
+//     shape (auChunkPattern) within it, the same way processWebpackFileRegex's
+//     auFunctionPattern scopes chunkPattern to that one function.
+//
+// jq-style extractors aren't implemented yet (no dependency here pulls in
+// a jq engine); Kind is reserved so one can be added later.
+type TemplateExtractor struct {
+	Name  string `yaml:"name"`
+	Kind  string `yaml:"kind"`
+	Regex string `yaml:"regex"`
+	Group int    `yaml:"group"`
+	// Path selects post-processing of an extracted path before it's
+	// resolved against the script's URL. "" leaves it untouched; "nextjs"
+	// restores the "_next/" prefix the way ensureNextPrefixIfWebpack does
+	// for the built-in adapters.
+	Path string `yaml:"path"`
+
+	re *regexp.Regexp
+}
+
+// templateMatch is what gets emitted for one extractor hit when
+// Options.OutputFormat is "jsonl": the resolved URL tagged with the
+// template and extractor that found it.
+type templateMatch struct {
+	Template  string `json:"template"`
+	Extractor string `json:"extractor"`
+	URL       string `json:"url"`
+}
+
+// LoadTemplates reads every *.yaml/*.yml file in dir and parses each into a
+// Template. A file that fails to parse or compile is skipped rather than
+// aborting the whole load, consistent with this tool's tolerance for
+// malformed input elsewhere (a bad template shouldn't take down a scan).
+func LoadTemplates(dir string) ([]*Template, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read templates dir: %s", err)
+	}
+
+	var out []*Template
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if !strings.HasSuffix(name, ".yaml") && !strings.HasSuffix(name, ".yml") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		var t Template
+		if err := yaml.Unmarshal(data, &t); err != nil {
+			continue
+		}
+		if err := t.compile(); err != nil {
+			continue
+		}
+		out = append(out, &t)
+	}
+	return out, nil
+}
+
+// compile pre-builds every extractor's regexp so Extract doesn't recompile
+// it on every call.
+func (t *Template) compile() error {
+	for _, ex := range t.Extractors {
+		re, err := regexp.Compile(ex.Regex)
+		if err != nil {
+			return fmt.Errorf("template %q: extractor %q: %s", t.TemplateName, ex.Name, err)
+		}
+		ex.re = re
+	}
+	return nil
+}
+
+func (t *Template) Name() string { return t.TemplateName }
+
+func (t *Template) Detect(u string, body []byte) bool {
+	if t.Match.URLContains == "" && len(t.Match.BodyContains) == 0 {
+		return false
+	}
+	if t.Match.URLContains != "" && !strings.Contains(u, t.Match.URLContains) {
+		return false
+	}
+	for _, needle := range t.Match.BodyContains {
+		if !bytes.Contains(body, []byte(needle)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t *Template) Extract(u string, body []byte) []string {
+	baseURL, err := url.Parse(u)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var out []string
+	emit := func(ex *TemplateExtractor, path string) {
+		if ex.Path == "nextjs" {
+			path = ensureNextPrefix(path)
+		}
+		resolved := resolveScriptURL(baseURL, path)
+		if seen[resolved] {
+			return
+		}
+		seen[resolved] = true
+		out = append(out, t.render(ex, resolved))
+	}
+
+	// seen is shared across every extractor below, same as
+	// processWebpackFileRegex's single processedPaths map: several of the
+	// patterns this template's extractors mirror can match the same chunk,
+	// and only the first extractor to find it should report (and tag) it.
+	for _, ex := range t.Extractors {
+		switch ex.Kind {
+		case "dictProduct":
+			for path := range t.extractDictProduct(ex, body) {
+				emit(ex, path)
+			}
+		case "auFunction":
+			for path := range t.extractAuFunction(ex, body) {
+				emit(ex, path)
+			}
+		default:
+			for _, m := range ex.re.FindAllSubmatch(body, -1) {
+				if ex.Group >= len(m) {
+					continue
+				}
+				emit(ex, string(m[ex.Group]))
+			}
+		}
+	}
+	return out
+}
+
+// render turns a resolved URL into the string Extract should return for it:
+// the bare URL normally, or a templateMatch JSON object tagged with the
+// template and extractor name when Options.OutputFormat is "jsonl" -
+// mirroring how resolveSourceMap JSON-encodes its results into the same
+// results channel when Options.OutputFormat is "sourcemap".
+func (t *Template) render(ex *TemplateExtractor, resolvedURL string) string {
+	if t.s == nil || t.s.opts.OutputFormat != "jsonl" {
+		return resolvedURL
+	}
+	out, err := json.Marshal(templateMatch{Template: t.TemplateName, Extractor: ex.Name, URL: resolvedURL})
+	if err != nil {
+		return resolvedURL
+	}
+	return string(out)
+}
+
+// extractDictProduct reproduces processWebpackFileRegex's complexPattern:
+// Regex must capture a base path, an id->name map literal and an
+// id->hash map literal, and every id present in the hash map yields one
+// chunk path, preferring the name map's entry when one exists for that id.
+func (t *Template) extractDictProduct(ex *TemplateExtractor, body []byte) map[string]bool {
+	paths := make(map[string]bool)
+	m := ex.re.FindSubmatch(body)
+	if len(m) < 4 {
+		return paths
+	}
+	basePath := string(m[1])
+	idMap := parseJSMap(string(m[2]))
+	hashMap := parseJSMap(string(m[3]))
+
+	for id, hash := range hashMap {
+		chunkName := id
+		if namedID, ok := idMap[id]; ok {
+			chunkName = namedID
+		}
+		paths[basePath+chunkName+"."+hash+".js"] = true
+	}
+	return paths
+}
+
+// auChunkPattern is the same numeric-id ternary shape as
+// processWebpackFileRegex's chunkPattern, applied only within the a.u
+// function body that extractAuFunction isolates first.
+var auChunkPattern = regexp.MustCompile(`(\d+)===e\?"([^"]+)"`)
+
+// extractAuFunction reproduces processWebpackFileRegex's Pattern 4: Regex
+// isolates the body of webpack's a.u=e=>... chunk-id function, and every
+// numeric-id ternary branch within that body yields one chunk path.
+func (t *Template) extractAuFunction(ex *TemplateExtractor, body []byte) map[string]bool {
+	paths := make(map[string]bool)
+	m := ex.re.FindSubmatch(body)
+	if len(m) < 2 {
+		return paths
+	}
+	for _, cm := range auChunkPattern.FindAllSubmatch(m[1], -1) {
+		paths[string(cm[2])] = true
+	}
+	return paths
+}
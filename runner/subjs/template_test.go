@@ -0,0 +1,197 @@
+package subjs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTemplate(t *testing.T, dir, name, contents string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+		t.Fatalf("could not write template: %s", err)
+	}
+}
+
+func TestLoadTemplatesSkipsInvalidFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "good.yaml", `
+name: good
+match:
+  urlContains: ".js"
+extractors:
+  - name: direct
+    regex: 'href="([^"]+\.js)"'
+    group: 1
+`)
+	writeTemplate(t, dir, "bad-regex.yaml", `
+name: bad
+match:
+  urlContains: ".js"
+extractors:
+  - name: broken
+    regex: '('
+`)
+	writeTemplate(t, dir, "not-yaml.txt", "ignored")
+
+	templates, err := LoadTemplates(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(templates) != 1 || templates[0].TemplateName != "good" {
+		t.Fatalf("expected only the valid template to load, got %v", templates)
+	}
+}
+
+func TestLoadTemplatesMissingDir(t *testing.T) {
+	if _, err := LoadTemplates(filepath.Join(t.TempDir(), "does-not-exist")); err == nil {
+		t.Fatal("expected an error for a missing templates dir")
+	}
+}
+
+func newCompiledTemplate(t *testing.T, yamlSrc string) *Template {
+	t.Helper()
+	dir := t.TempDir()
+	writeTemplate(t, dir, "t.yaml", yamlSrc)
+	templates, err := LoadTemplates(dir)
+	if err != nil || len(templates) != 1 {
+		t.Fatalf("could not load template: err=%s templates=%v", err, templates)
+	}
+	return templates[0]
+}
+
+func TestTemplateDetectRequiresMatchCriteria(t *testing.T) {
+	tmpl := newCompiledTemplate(t, `
+name: remix
+match:
+  urlContains: "/build/"
+  bodyContains: ["__remixManifest"]
+extractors:
+  - name: direct
+    regex: '"([^"]+\.js)"'
+    group: 1
+`)
+
+	if tmpl.Detect("https://example.com/build/main.js", []byte("no marker here")) {
+		t.Fatal("expected Detect to require bodyContains as well as urlContains")
+	}
+	if !tmpl.Detect("https://example.com/build/main.js", []byte("window.__remixManifest = {}")) {
+		t.Fatal("expected Detect to match when both urlContains and bodyContains are satisfied")
+	}
+}
+
+func TestTemplateDetectWithNeitherCriterionNeverMatches(t *testing.T) {
+	tmpl := &Template{TemplateName: "empty"}
+	if tmpl.Detect("https://example.com/main.js", []byte("anything")) {
+		t.Fatal("expected a Template with no match criteria to never match")
+	}
+}
+
+func TestTemplateExtractDefaultKind(t *testing.T) {
+	tmpl := newCompiledTemplate(t, `
+name: islands
+match:
+  urlContains: ".js"
+extractors:
+  - name: island
+    regex: 'import\("([^"]+\.js)"\)'
+    group: 1
+`)
+
+	body := []byte(`import("/islands/header.js"); import("/islands/footer.js");`)
+	got := tmpl.Extract("https://example.com/app.js", body)
+	if len(got) != 2 || got[0] != "https://example.com/islands/header.js" || got[1] != "https://example.com/islands/footer.js" {
+		t.Fatalf("got %v", got)
+	}
+}
+
+func TestTemplateExtractDictProduct(t *testing.T) {
+	tmpl := newCompiledTemplate(t, `
+name: webpack-dict
+match:
+  urlContains: "_next/static"
+extractors:
+  - name: dict-chunk
+    kind: dictProduct
+    regex: '"(static/chunks/)"\+\(\({([^}]+)}\)\[e\]\|\|e\)\+"\."\+\({([^}]+)}\)\[e\]\+"\.js"'
+    path: nextjs
+`)
+
+	body := []byte(`"static/chunks/"+(({1027:"about",142:"contact"})[e]||e)+"."+({1027:"4b26d002",142:"b1a9bae1"})[e]+".js"`)
+	got := tmpl.Extract("https://example.com/_next/static/chunks/main.js", body)
+
+	want := map[string]bool{
+		"https://example.com/_next/static/chunks/about.4b26d002.js":   true,
+		"https://example.com/_next/static/chunks/contact.b1a9bae1.js": true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for _, u := range got {
+		if !want[u] {
+			t.Fatalf("unexpected chunk %q, got %v", u, got)
+		}
+	}
+}
+
+func TestTemplateExtractAuFunction(t *testing.T) {
+	tmpl := newCompiledTemplate(t, `
+name: webpack-au
+match:
+  urlContains: "_next/static"
+extractors:
+  - name: au-chunk
+    kind: auFunction
+    regex: 'a\.u=e=>([^}]+)'
+    path: nextjs
+`)
+
+	body := []byte(`a.u=e=>2986===e?"static/chunks/2986-2488e3e4.js":7699===e?"static/chunks/7699-abc123.js":e}`)
+	got := tmpl.Extract("https://example.com/_next/static/chunks/main.js", body)
+
+	want := map[string]bool{
+		"https://example.com/_next/static/chunks/2986-2488e3e4.js": true,
+		"https://example.com/_next/static/chunks/7699-abc123.js":   true,
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v", got)
+	}
+	for _, u := range got {
+		if !want[u] {
+			t.Fatalf("unexpected chunk %q, got %v", u, got)
+		}
+	}
+}
+
+func TestTemplateRenderTagsJSONLOutput(t *testing.T) {
+	tmpl := newCompiledTemplate(t, `
+name: islands
+match:
+  urlContains: ".js"
+extractors:
+  - name: island
+    regex: 'import\("([^"]+\.js)"\)'
+    group: 1
+`)
+	tmpl.s = &SubJS{opts: &Options{OutputFormat: "jsonl"}}
+
+	body := []byte(`import("/islands/header.js");`)
+	got := tmpl.Extract("https://example.com/app.js", body)
+	if len(got) != 1 {
+		t.Fatalf("got %v", got)
+	}
+	if !strings.Contains(got[0], `"template":"islands"`) || !strings.Contains(got[0], `"extractor":"island"`) {
+		t.Fatalf("expected the jsonl-tagged match, got %q", got[0])
+	}
+}
+
+func TestBundledNextJSTemplateLoads(t *testing.T) {
+	templates, err := LoadTemplates("../../templates")
+	if err != nil {
+		t.Fatalf("unexpected error loading the bundled templates dir: %s", err)
+	}
+	if len(templates) != 1 || templates[0].TemplateName != "nextjs-webpack" {
+		t.Fatalf("expected the bundled nextjs-webpack template to load, got %v", templates)
+	}
+}